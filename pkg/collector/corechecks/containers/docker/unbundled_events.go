@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build docker
+
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/tagger"
+	taggertypes "github.com/DataDog/datadog-agent/comp/core/tagger/types"
+	"github.com/DataDog/datadog-agent/pkg/metrics/event"
+	"github.com/DataDog/datadog-agent/pkg/util/containers"
+	"github.com/DataDog/datadog-agent/pkg/util/docker"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	defaultUnbundledTitleTemplate = "Container {{.ContainerID}}: {{.Action}}"
+	defaultUnbundledTextTemplate  = `Container {{.ContainerID}} (running image "{{.ImageName}}"): {{.Action}}`
+)
+
+// defaultUnbundledActions matches the allow-list unbundled events had before
+// `docker_check.unbundled_events` made it configurable.
+var defaultUnbundledActions = []string{"oom", "kill"}
+
+// unbundledEventPolicy configures how a single docker event action is
+// rendered into a metrics event. TitleTemplate and TextTemplate are
+// text/template strings rendered with the *docker.ContainerEvent as data.
+type unbundledEventPolicy struct {
+	AlertType     event.EventAlertType `mapstructure:"alert_type"`
+	Priority      event.EventPriority  `mapstructure:"priority"`
+	TitleTemplate string               `mapstructure:"title_template"`
+	TextTemplate  string               `mapstructure:"text_template"`
+	ExtraTags     []string             `mapstructure:"extra_tags"`
+}
+
+// withOverrides returns p with any non-zero field of o applied on top,
+// so a user config only needs to set the fields it wants to change -
+// e.g. just alert_type to promote `die` to a warning - without having to
+// restate the rest of the policy.
+func (p unbundledEventPolicy) withOverrides(o unbundledEventPolicy) unbundledEventPolicy {
+	if o.AlertType != "" {
+		p.AlertType = o.AlertType
+	}
+	if o.Priority != "" {
+		p.Priority = o.Priority
+	}
+	if o.TitleTemplate != "" {
+		p.TitleTemplate = o.TitleTemplate
+	}
+	if o.TextTemplate != "" {
+		p.TextTemplate = o.TextTemplate
+	}
+	if len(o.ExtraTags) > 0 {
+		p.ExtraTags = append(append([]string{}, p.ExtraTags...), o.ExtraTags...)
+	}
+	return p
+}
+
+// defaultUnbundledEventPolicy is used for an allow-listed action with no
+// matching entry in defaultUnbundledEventPolicies or the user config.
+var defaultUnbundledEventPolicy = unbundledEventPolicy{
+	AlertType:     event.EventAlertTypeInfo,
+	Priority:      event.EventPriorityNormal,
+	TitleTemplate: defaultUnbundledTitleTemplate,
+	TextTemplate:  defaultUnbundledTextTemplate,
+}
+
+// defaultUnbundledEventPolicies seeds the policy for actions whose severity
+// predates `docker_check.unbundled_events` becoming configurable.
+var defaultUnbundledEventPolicies = map[string]unbundledEventPolicy{
+	"oom": defaultUnbundledEventPolicy.withOverrides(unbundledEventPolicy{
+		AlertType: event.EventAlertTypeError,
+	}),
+}
+
+// unbundledEventPoliciesFromConfig reads docker_check.unbundled_events from
+// cfg into an allow-listed action list and their per-action policies,
+// falling back to defaultUnbundledActions when the list isn't configured.
+func unbundledEventPoliciesFromConfig(cfg config.Reader) ([]string, map[string]unbundledEventPolicy) {
+	actions := cfg.GetStringSlice("docker_check.unbundled_events.actions")
+	if len(actions) == 0 {
+		actions = defaultUnbundledActions
+	}
+
+	policies := map[string]unbundledEventPolicy{}
+	if cfg.IsSet("docker_check.unbundled_events.policies") {
+		if err := cfg.UnmarshalKey("docker_check.unbundled_events.policies", &policies); err != nil {
+			log.Warnf("could not parse docker_check.unbundled_events.policies, falling back to defaults: %v", err)
+			policies = map[string]unbundledEventPolicy{}
+		}
+	}
+	return actions, policies
+}
+
+// unbundledTransformer turns allow-listed docker container events into
+// individual metrics events, instead of folding them into the bundled
+// "docker events" check output, using a per-action template-driven policy.
+type unbundledTransformer struct {
+	hostname string
+	policies map[string]unbundledEventPolicy
+}
+
+// newUnbundledTransformer builds an unbundledTransformer allowing actions,
+// with each action's rendering policy taken from defaultUnbundledEventPolicies
+// (or defaultUnbundledEventPolicy if unset) and overridden by any matching
+// entry in configured.
+func newUnbundledTransformer(hostname string, actions []string, configured map[string]unbundledEventPolicy) *unbundledTransformer {
+	policies := make(map[string]unbundledEventPolicy, len(actions))
+	for _, action := range actions {
+		policy := defaultUnbundledEventPolicy
+		if d, ok := defaultUnbundledEventPolicies[action]; ok {
+			policy = d
+		}
+		if override, ok := configured[action]; ok {
+			policy = policy.withOverrides(override)
+		}
+		policies[action] = policy
+	}
+	return &unbundledTransformer{hostname: hostname, policies: policies}
+}
+
+// Transform converts the allow-listed events in events into metrics events,
+// rendering each one through its configured policy. An action not in the
+// transformer's allow-list is silently dropped, matching the check's
+// existing filtering behavior. A template error for one event is collected
+// into the returned errors slice rather than aborting the whole batch or
+// being dropped silently.
+func (t *unbundledTransformer) Transform(events []*docker.ContainerEvent) ([]event.Event, []error) {
+	var out []event.Event
+	var errs []error
+
+	for _, ev := range events {
+		policy, ok := t.policies[ev.Action]
+		if !ok {
+			continue
+		}
+
+		title, err := renderUnbundledTemplate("title", policy.TitleTemplate, ev)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not render title template for %s event on container %s: %w", ev.Action, ev.ContainerID, err))
+			continue
+		}
+		text, err := renderUnbundledTemplate("text", policy.TextTemplate, ev)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not render text template for %s event on container %s: %w", ev.Action, ev.ContainerID, err))
+			continue
+		}
+
+		entityID := containers.BuildTaggerEntityName(ev.ContainerID)
+		tags, err := tagger.Tag(entityID, taggertypes.LowCardinality)
+		if err != nil {
+			log.Debugf("could not collect tags for container %s: %v", ev.ContainerID, err)
+		}
+		tags = append(append([]string{}, tags...), "event_type:"+ev.Action)
+		tags = append(tags, policy.ExtraTags...)
+
+		out = append(out, event.Event{
+			Title:          title,
+			Text:           text,
+			AlertType:      policy.AlertType,
+			AggregationKey: "docker:" + ev.ContainerID,
+			Ts:             ev.Timestamp.Unix(),
+			Host:           t.hostname,
+			SourceTypeName: "docker",
+			EventType:      "docker",
+			Priority:       policy.Priority,
+			Tags:           tags,
+		})
+	}
+
+	return out, errs
+}
+
+// renderUnbundledTemplate executes a text/template string with ev as data.
+func renderUnbundledTemplate(name string, tmplText string, ev *docker.ContainerEvent) (string, error) {
+	tmpl, err := template.New(name).Parse(tmplText)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -36,11 +36,15 @@ func TestUnbundledEventsTransform(t *testing.T) {
 
 	tests := []struct {
 		name     string
+		actions  []string
+		policies map[string]unbundledEventPolicy
 		event    *docker.ContainerEvent
 		expected []event.Event
+		wantErrs int
 	}{
 		{
-			name: "event is filtered out",
+			name:    "event is filtered out",
+			actions: []string{"oom", "kill"},
 			event: &docker.ContainerEvent{
 				ContainerID:   containerID,
 				ContainerName: containerName,
@@ -51,7 +55,8 @@ func TestUnbundledEventsTransform(t *testing.T) {
 			expected: nil,
 		},
 		{
-			name: "event is filtered out",
+			name:    "oom falls through to the default severity policy",
+			actions: []string{"oom", "kill"},
 			event: &docker.ContainerEvent{
 				ContainerID:   containerID,
 				ContainerName: containerName,
@@ -78,15 +83,100 @@ func TestUnbundledEventsTransform(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "allow-listed action with no configured policy uses the generic default",
+			actions: []string{"die"},
+			event: &docker.ContainerEvent{
+				ContainerID:   containerID,
+				ContainerName: containerName,
+				ImageName:     imageName,
+				Action:        "die",
+				Timestamp:     ts,
+			},
+			expected: []event.Event{
+				{
+					Title:          "Container foobar: die",
+					Text:           "Container foobar (running image \"foo:latest\"): die",
+					AlertType:      event.EventAlertTypeInfo,
+					AggregationKey: "docker:foobar",
+					Ts:             ts.Unix(),
+					Host:           hostname,
+					SourceTypeName: "docker",
+					EventType:      "docker",
+					Priority:       event.EventPriorityNormal,
+					Tags: []string{
+						"image_name:foo",
+						"image_tag:latest",
+						"event_type:die",
+					},
+				},
+			},
+		},
+		{
+			name:    "configured policy overrides severity, templates and extra tags",
+			actions: []string{"die"},
+			policies: map[string]unbundledEventPolicy{
+				"die": {
+					AlertType:     event.EventAlertTypeWarning,
+					TitleTemplate: "{{.ContainerName}} died",
+					TextTemplate:  "{{.ContainerName}} ({{.ContainerID}}) died",
+					ExtraTags:     []string{"runbook_url:https://example.com/die"},
+				},
+			},
+			event: &docker.ContainerEvent{
+				ContainerID:   containerID,
+				ContainerName: containerName,
+				ImageName:     imageName,
+				Action:        "die",
+				Timestamp:     ts,
+			},
+			expected: []event.Event{
+				{
+					Title:          "foo died",
+					Text:           "foo (foobar) died",
+					AlertType:      event.EventAlertTypeWarning,
+					AggregationKey: "docker:foobar",
+					Ts:             ts.Unix(),
+					Host:           hostname,
+					SourceTypeName: "docker",
+					EventType:      "docker",
+					Priority:       event.EventPriorityNormal,
+					Tags: []string{
+						"image_name:foo",
+						"image_tag:latest",
+						"event_type:die",
+						"runbook_url:https://example.com/die",
+					},
+				},
+			},
+		},
+		{
+			name:    "a malformed template surfaces as an error instead of being dropped silently",
+			actions: []string{"die"},
+			policies: map[string]unbundledEventPolicy{
+				"die": {
+					TitleTemplate: "{{.NotAField}}",
+				},
+			},
+			event: &docker.ContainerEvent{
+				ContainerID:   containerID,
+				ContainerName: containerName,
+				ImageName:     imageName,
+				Action:        "die",
+				Timestamp:     ts,
+			},
+			expected: nil,
+			wantErrs: 1,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			transformer := newUnbundledTransformer(hostname, []string{"oom", "kill"})
+			transformer := newUnbundledTransformer(hostname, tt.actions, tt.policies)
 
-			events, errors := transformer.Transform([]*docker.ContainerEvent{tt.event})
+			events, errs := transformer.Transform([]*docker.ContainerEvent{tt.event})
 
-			assert.Empty(t, errors)
+			assert.Len(t, errs, tt.wantErrs)
 			assert.Equal(t, tt.expected, events)
 		})
 	}
@@ -21,11 +21,11 @@ import (
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/pkg/config/remote/client"
-	"github.com/DataDog/datadog-agent/pkg/config/utils"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
 	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	// registers the "oci" and "containerd" installer backends
+	_ "github.com/DataDog/datadog-agent/pkg/fleet/installer/oci"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
-	"github.com/DataDog/datadog-agent/pkg/fleet/internal/exec"
 	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -41,12 +41,33 @@ type Daemon interface {
 	Stop(ctx context.Context) error
 
 	Install(ctx context.Context, url string) error
+	Upgrade(ctx context.Context, pkg string, url string, opts UpgradeOptions) error
 	StartExperiment(ctx context.Context, url string) error
 	StopExperiment(ctx context.Context, pkg string) error
 	PromoteExperiment(ctx context.Context, pkg string) error
 
 	GetPackage(pkg string, version string) (Package, error)
 	GetState() (map[string]repository.State, error)
+
+	// BackendCapabilities reports which optional features the configured
+	// installer backend supports, so callers can e.g. avoid requesting an
+	// UpgradeStrategyCanary against a backend that can't do canaries.
+	BackendCapabilities() installer.Capabilities
+
+	// Subscribe returns a stream of typed lifecycle events matching filter
+	// and a CancelFunc to release the subscription. Multiple concurrent
+	// subscribers are supported; a slow subscriber has events dropped for it
+	// rather than blocking other subscribers or the daemon itself.
+	Subscribe(filter EventFilter) (<-chan Event, CancelFunc)
+}
+
+// rcClient is the subset of *remoteConfig the daemon depends on, factored out
+// as an interface so tests can exercise daemonImpl without a real remote
+// config client.
+type rcClient interface {
+	Start(onCatalogUpdate func(catalog) error, onRemoteRequest func(remoteAPIRequest) error)
+	Close()
+	SetState(packages []*pbgo.PackageState)
 }
 
 type daemonImpl struct {
@@ -55,18 +76,18 @@ type daemonImpl struct {
 
 	installer     installer.Installer
 	remoteUpdates bool
-	rc            *remoteConfig
+	rc            rcClient
 	catalog       catalog
 	requests      chan remoteAPIRequest
 	requestsWG    sync.WaitGroup
+	events        *eventBus
+	wal           *requestWAL
+	retries       *retryTracker
 }
 
-func newInstaller(config config.Reader, installerBin string) installer.Installer {
-	registry := config.GetString("updater.registry")
-	registryAuth := config.GetString("updater.registry_auth")
-	apiKey := utils.SanitizeAPIKey(config.GetString("api_key"))
-	site := config.GetString("site")
-	return exec.NewInstallerExec(installerBin, registry, registryAuth, apiKey, site)
+func newInstaller(config config.Reader, installerBin string) (installer.Installer, error) {
+	backend := installer.BackendName(config.GetString("updater.backend"))
+	return installer.NewBackend(backend, config, installerBin)
 }
 
 // NewDaemon returns a new daemon.
@@ -83,24 +104,43 @@ func NewDaemon(rcFetcher client.ConfigFetcher, config config.Reader) (Daemon, er
 	if err != nil {
 		return nil, fmt.Errorf("could not create remote config client: %w", err)
 	}
-	installer := newInstaller(config, installerBin)
+	installer, err := newInstaller(config, installerBin)
+	if err != nil {
+		return nil, fmt.Errorf("could not create installer backend: %w", err)
+	}
+	wal, err := newRequestWAL(filepath.Join(config.GetString("run_path"), "fleet"))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request WAL: %w", err)
+	}
 	remoteUpdates := config.GetBool("updater.remote_updates")
-	return newDaemon(rc, installer, remoteUpdates), nil
+	return newDaemon(rc, installer, wal, remoteUpdates), nil
 }
 
-func newDaemon(rc *remoteConfig, installer installer.Installer, remoteUpdates bool) *daemonImpl {
+func newDaemon(rc rcClient, installer installer.Installer, wal *requestWAL, remoteUpdates bool) *daemonImpl {
 	i := &daemonImpl{
 		remoteUpdates: remoteUpdates,
 		rc:            rc,
 		installer:     installer,
+		wal:           wal,
 		requests:      make(chan remoteAPIRequest, 32),
 		catalog:       catalog{},
 		stopChan:      make(chan struct{}),
+		events:        newEventBus(),
+		retries:       newRetryTracker(),
 	}
 	i.refreshState(context.Background())
 	return i
 }
 
+// BackendCapabilities reports which optional features the configured
+// installer backend supports.
+func (d *daemonImpl) BackendCapabilities() installer.Capabilities {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	return installer.CapabilitiesOf(d.installer)
+}
+
 // GetState returns the state.
 func (d *daemonImpl) GetState() (map[string]repository.State, error) {
 	d.m.Lock()
@@ -116,13 +156,28 @@ func (d *daemonImpl) GetPackage(pkg string, version string) (Package, error) {
 
 	catalogPackage, ok := d.catalog.getPackage(pkg, version, runtime.GOARCH, runtime.GOOS)
 	if !ok {
-		return Package{}, fmt.Errorf("could not get package %s, %s for %s, %s", pkg, version, runtime.GOARCH, runtime.GOOS)
+		return Package{}, installerErrors.NotFound(fmt.Errorf("could not get package %s, %s for %s, %s", pkg, version, runtime.GOARCH, runtime.GOOS))
 	}
 	return catalogPackage, nil
 }
 
-// Start starts remote config and the garbage collector.
-func (d *daemonImpl) Start(_ context.Context) error {
+// Start replays any remote API requests left pending by a previous process
+// in the request WAL, then starts remote config and the garbage collector.
+func (d *daemonImpl) Start(ctx context.Context) error {
+	pending, err := d.wal.pending()
+	if err != nil {
+		log.Errorf("Daemon: could not read request WAL, pending requests will not be replayed: %v", err)
+	}
+	for _, request := range pending {
+		log.Infof("Daemon: replaying request %s for package %s left pending by a previous run", request.ID, request.Package)
+		d.requestsWG.Add(1)
+		if err := d.handleRemoteAPIRequest(request); err != nil {
+			log.Errorf("Daemon: could not replay request %s: %v", request.ID, err)
+		} else {
+			d.retries.forget(request.ID)
+		}
+	}
+
 	d.m.Lock()
 	defer d.m.Unlock()
 	go func() {
@@ -133,15 +188,30 @@ func (d *daemonImpl) Start(_ context.Context) error {
 				err := d.installer.GarbageCollect(context.Background())
 				d.m.Unlock()
 				if err != nil {
-					log.Errorf("Daemon: could not run GC: %v", err)
+					log.Errorf("Daemon: could not run GC: %v", installerErrors.System(err))
+				} else {
+					d.events.publish(Event{Kind: EventKindGarbageCollected, Time: time.Now()})
 				}
 			case <-d.stopChan:
 				return
 			case request := <-d.requests:
 				err := d.handleRemoteAPIRequest(request)
-				if err != nil {
-					log.Errorf("Daemon: could not handle remote request: %v", err)
+				if err == nil {
+					d.retries.forget(request.ID)
+					continue
 				}
+				if ie, ok := installerErrors.AsInstallerError(err); ok && ie.IsRetryable() {
+					if delay, retry := d.retries.next(request.ID); retry {
+						log.Warnf("Daemon: retryable error handling remote request %s, retrying in %s: %v", request.ID, delay, err)
+						d.retries.schedule(delay, func() {
+							_ = d.scheduleRemoteAPIRequest(request)
+						})
+						continue
+					}
+					log.Errorf("Daemon: giving up on remote request %s after %d attempts: %v", request.ID, maxRequestRetries, err)
+					continue
+				}
+				log.Errorf("Daemon: could not handle remote request: %v", err)
 			}
 		}
 	}()
@@ -158,6 +228,11 @@ func (d *daemonImpl) Stop(_ context.Context) error {
 	d.m.Lock()
 	defer d.m.Unlock()
 	d.rc.Close()
+	// Stop any pending retry before waiting: once this returns, no retry
+	// scheduled earlier can call back into scheduleRemoteAPIRequest - which
+	// would otherwise risk an Add on requestsWG after Wait below has
+	// already returned.
+	d.retries.stopAll()
 	close(d.stopChan)
 	d.requestsWG.Wait()
 	return nil
@@ -167,20 +242,30 @@ func (d *daemonImpl) Stop(_ context.Context) error {
 func (d *daemonImpl) Install(ctx context.Context, url string) error {
 	d.m.Lock()
 	defer d.m.Unlock()
-	return d.install(ctx, url)
+	return d.install(ctx, "", url)
 }
 
-func (d *daemonImpl) install(ctx context.Context, url string) (err error) {
+// install installs the package from url, publishing its lifecycle events
+// tagged with pkg. Callers that already know the package name (e.g. from the
+// catalog or a remote request) should pass it; it is otherwise parsed out of
+// url, since Install itself is never given it explicitly.
+func (d *daemonImpl) install(ctx context.Context, pkg string, url string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "install")
 	defer func() { span.Finish(tracer.WithError(err)) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
+	if pkg == "" {
+		pkg = installer.PackageNameFromURL(url)
+	}
 	log.Infof("Daemon: Installing package from %s", url)
+	d.events.publish(Event{Kind: EventKindInstallStarted, Package: pkg, Time: time.Now()})
 	err = d.installer.Install(ctx, url)
 	if err != nil {
-		return fmt.Errorf("could not install: %w", err)
+		d.events.publish(Event{Kind: EventKindInstallFailed, Package: pkg, Err: err, Time: time.Now()})
+		return fmt.Errorf("could not install: %w", installerErrors.From(err))
 	}
+	d.events.publish(Event{Kind: EventKindInstallSucceeded, Package: pkg, Time: time.Now()})
 	log.Infof("Daemon: Successfully installed package from %s", url)
 	return nil
 }
@@ -189,20 +274,27 @@ func (d *daemonImpl) install(ctx context.Context, url string) (err error) {
 func (d *daemonImpl) StartExperiment(ctx context.Context, url string) error {
 	d.m.Lock()
 	defer d.m.Unlock()
-	return d.startExperiment(ctx, url)
+	return d.startExperiment(ctx, "", url)
 }
 
-func (d *daemonImpl) startExperiment(ctx context.Context, url string) (err error) {
+// startExperiment starts an experiment for url, publishing its lifecycle
+// event tagged with pkg if known, or the package name parsed out of url
+// otherwise - see install for why.
+func (d *daemonImpl) startExperiment(ctx context.Context, pkg string, url string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "start_experiment")
 	defer func() { span.Finish(tracer.WithError(err)) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
+	if pkg == "" {
+		pkg = installer.PackageNameFromURL(url)
+	}
 	log.Infof("Daemon: Starting experiment for package from %s", url)
 	err = d.installer.InstallExperiment(ctx, url)
 	if err != nil {
-		return fmt.Errorf("could not install experiment: %w", err)
+		return fmt.Errorf("could not install experiment: %w", installerErrors.From(err))
 	}
+	d.events.publish(Event{Kind: EventKindExperimentStarted, Package: pkg, Time: time.Now()})
 	log.Infof("Daemon: Successfully started experiment for package from %s", url)
 	return nil
 }
@@ -223,8 +315,9 @@ func (d *daemonImpl) promoteExperiment(ctx context.Context, pkg string) (err err
 	log.Infof("Daemon: Promoting experiment for package %s", pkg)
 	err = d.installer.PromoteExperiment(ctx, pkg)
 	if err != nil {
-		return fmt.Errorf("could not promote experiment: %w", err)
+		return fmt.Errorf("could not promote experiment: %w", installerErrors.From(err))
 	}
+	d.events.publish(Event{Kind: EventKindExperimentPromoted, Package: pkg, Time: time.Now()})
 	log.Infof("Daemon: Successfully promoted experiment for package %s", pkg)
 	return nil
 }
@@ -245,8 +338,9 @@ func (d *daemonImpl) stopExperiment(ctx context.Context, pkg string) (err error)
 	log.Infof("Daemon: Stopping experiment for package %s", pkg)
 	err = d.installer.RemoveExperiment(ctx, pkg)
 	if err != nil {
-		return fmt.Errorf("could not stop experiment: %w", err)
+		return fmt.Errorf("could not stop experiment: %w", installerErrors.From(err))
 	}
+	d.events.publish(Event{Kind: EventKindExperimentStopped, Package: pkg, Time: time.Now()})
 	log.Infof("Daemon: Successfully stopped experiment for package %s", pkg)
 	return nil
 }
@@ -256,10 +350,18 @@ func (d *daemonImpl) handleCatalogUpdate(c catalog) error {
 	defer d.m.Unlock()
 	log.Infof("Installer: Received catalog update")
 	d.catalog = c
+	d.events.publish(Event{Kind: EventKindCatalogUpdated, Time: time.Now()})
 	return nil
 }
 
+// scheduleRemoteAPIRequest enqueues request for the worker goroutine started
+// by Start. It persists request to the WAL before enqueuing it, not after
+// it's dequeued, so a crash while it's sitting in the in-memory channel still
+// leaves a pending entry behind to replay.
 func (d *daemonImpl) scheduleRemoteAPIRequest(request remoteAPIRequest) error {
+	if err := d.wal.append(request); err != nil {
+		log.Errorf("Daemon: could not persist request %s to WAL: %v", request.ID, err)
+	}
 	d.requestsWG.Add(1)
 	d.requests <- request
 	return nil
@@ -276,15 +378,25 @@ func (d *daemonImpl) handleRemoteAPIRequest(request remoteAPIRequest) (err error
 
 	s, err := d.installer.State(request.Package)
 	if err != nil {
-		return fmt.Errorf("could not get installer state: %w", err)
+		return fmt.Errorf("could not get installer state: %w", installerErrors.System(err))
 	}
 	if s.Stable != request.ExpectedState.Stable || s.Experiment != request.ExpectedState.Experiment {
 		log.Infof("remote request %s not executed as state does not match: expected %v, got %v", request.ID, request.ExpectedState, s)
 		setRequestInvalid(ctx)
+		if err := d.wal.complete(request.ID); err != nil {
+			log.Errorf("Daemon: could not clear request %s from WAL: %v", request.ID, err)
+		}
 		d.refreshState(ctx)
 		return nil
 	}
-	defer func() { setRequestDone(ctx, err) }()
+	// request was already persisted to the WAL by scheduleRemoteAPIRequest
+	// (or, for a request replayed from a previous run, is already there).
+	defer func() {
+		setRequestDone(ctx, err)
+		if walErr := d.wal.complete(request.ID); walErr != nil {
+			log.Errorf("Daemon: could not clear request %s from WAL: %v", request.ID, walErr)
+		}
+	}()
 
 	switch request.Method {
 	case methodStartExperiment:
@@ -295,16 +407,28 @@ func (d *daemonImpl) handleRemoteAPIRequest(request remoteAPIRequest) (err error
 		}
 		experimentPackage, ok := d.catalog.getPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
 		if !ok {
-			return fmt.Errorf("could not get package %s, %s for %s, %s", request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+			return installerErrors.NotFound(fmt.Errorf("could not get package %s, %s for %s, %s", request.Package, params.Version, runtime.GOARCH, runtime.GOOS))
 		}
 		log.Infof("Installer: Received remote request %s to start experiment for package %s version %s", request.ID, request.Package, request.Params)
-		return d.startExperiment(ctx, experimentPackage.URL)
+		return d.startExperiment(ctx, request.Package, experimentPackage.URL)
 	case methodStopExperiment:
 		log.Infof("Installer: Received remote request %s to stop experiment for package %s", request.ID, request.Package)
 		return d.stopExperiment(ctx, request.Package)
 	case methodPromoteExperiment:
 		log.Infof("Installer: Received remote request %s to promote experiment for package %s", request.ID, request.Package)
 		return d.promoteExperiment(ctx, request.Package)
+	case methodUpgrade:
+		var params taskWithVersionParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal upgrade params: %w", err)
+		}
+		upgradePackage, ok := d.catalog.getPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+		if !ok {
+			return fmt.Errorf("could not get package %s, %s for %s, %s", request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+		}
+		log.Infof("Installer: Received remote request %s to upgrade package %s to version %s", request.ID, request.Package, params.Version)
+		return d.upgrade(ctx, request.Package, upgradePackage.URL, UpgradeOptions{Strategy: UpgradeStrategyViaExperiment})
 	default:
 		return fmt.Errorf("unknown method: %s", request.Method)
 	}
@@ -343,6 +467,17 @@ func newRequestContext(request remoteAPIRequest) (ddtrace.Span, context.Context)
 	return tracer.StartSpanFromContext(ctx, "remote_request", tracer.ChildOf(spanCtx))
 }
 
+// IdempotencyKey returns the ID of the remote API request being executed in
+// ctx, if any. Installer backends can use it to dedupe the at-least-once
+// execution the request WAL replay guarantees after a daemon restart.
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	state, ok := ctx.Value(requestStateKey).(*requestState)
+	if !ok {
+		return "", false
+	}
+	return state.ID, true
+}
+
 func setRequestInvalid(ctx context.Context) {
 	state := ctx.Value(requestStateKey).(*requestState)
 	state.State = pbgo.TaskState_INVALID_STATE
@@ -0,0 +1,182 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// EventKind identifies the kind of lifecycle event published by the daemon.
+type EventKind string
+
+const (
+	// EventKindInstallStarted is published when an install begins.
+	EventKindInstallStarted EventKind = "install_started"
+	// EventKindInstallSucceeded is published when an install completes successfully.
+	EventKindInstallSucceeded EventKind = "install_succeeded"
+	// EventKindInstallFailed is published when an install fails.
+	EventKindInstallFailed EventKind = "install_failed"
+	// EventKindExperimentStarted is published when an experiment is started.
+	EventKindExperimentStarted EventKind = "experiment_started"
+	// EventKindExperimentPromoted is published when an experiment is promoted to stable.
+	EventKindExperimentPromoted EventKind = "experiment_promoted"
+	// EventKindExperimentStopped is published when an experiment is stopped.
+	EventKindExperimentStopped EventKind = "experiment_stopped"
+	// EventKindGarbageCollected is published after a GC pass completes.
+	EventKindGarbageCollected EventKind = "garbage_collected"
+	// EventKindCatalogUpdated is published when a new catalog is received from RC.
+	EventKindCatalogUpdated EventKind = "catalog_updated"
+)
+
+// Event is a single typed lifecycle event published by the daemon. Consumers
+// obtain a stream of these through Daemon.Subscribe instead of polling GetState.
+type Event struct {
+	Kind    EventKind
+	Package string
+	Version string
+	Err     error
+	Time    time.Time
+}
+
+// EventFilter restricts a subscription to a subset of packages and kinds. A
+// nil/empty field matches everything for that dimension.
+type EventFilter struct {
+	// Packages, when non-empty, only delivers events for these package names.
+	Packages []string
+	// Kinds, when non-empty, only delivers events of these kinds.
+	Kinds []EventKind
+	// ReplayLast, when > 0, replays up to that many recent matching events to
+	// the subscriber before live events start flowing, so late subscribers
+	// can catch up on history they missed.
+	ReplayLast int
+}
+
+func (f EventFilter) matches(e Event) bool {
+	if len(f.Packages) > 0 && !slices.Contains(f.Packages, e.Package) {
+		return false
+	}
+	if len(f.Kinds) > 0 && !slices.Contains(f.Kinds, e.Kind) {
+		return false
+	}
+	return true
+}
+
+// CancelFunc unsubscribes a subscriber created by Subscribe and releases its
+// buffer. It is safe to call more than once.
+type CancelFunc func()
+
+const (
+	// subscriberBufferSize bounds how many events a slow subscriber can lag
+	// behind before new events are dropped for it.
+	subscriberBufferSize = 64
+	// eventHistorySize bounds how many past events the bus retains for replay.
+	eventHistorySize = 256
+)
+
+// eventSubscriber is a single subscription's channel and bookkeeping.
+type eventSubscriber struct {
+	filter  EventFilter
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+// eventBus fans published daemon events out to subscribers, applying a
+// per-subscriber filter and a bounded buffer so a slow consumer cannot block
+// the daemon: once its buffer is full, further events are dropped and
+// counted rather than blocking the publisher.
+type eventBus struct {
+	m           sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	history     []Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// publish delivers an event to every matching subscriber and records it in
+// the replay history. It never blocks: a subscriber whose buffer is full has
+// the event dropped and its dropped counter incremented.
+func (b *eventBus) publish(e Event) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.history = append(b.history, e)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for s := range b.subscribers {
+		if !s.filter.matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			n := s.dropped.Add(1)
+			log.Warnf("Daemon: dropping event %s for package %s, subscriber is falling behind (%d dropped so far)", e.Kind, e.Package, n)
+		}
+	}
+}
+
+// subscribe registers a new subscriber and returns its event channel along
+// with a CancelFunc to unsubscribe. If filter.ReplayLast > 0, up to that many
+// matching past events are delivered on the channel before it returns.
+func (b *eventBus) subscribe(filter EventFilter) (<-chan Event, CancelFunc) {
+	s := &eventSubscriber{
+		filter: filter,
+		ch:     make(chan Event, subscriberBufferSize),
+	}
+
+	b.m.Lock()
+	b.subscribers[s] = struct{}{}
+	if filter.ReplayLast > 0 {
+		// Collect the actually-matched events during the backward scan,
+		// rather than slicing history by count: the last N matches aren't
+		// necessarily the last N entries once other event kinds interleave.
+		matched := make([]Event, 0, filter.ReplayLast)
+		for i := len(b.history) - 1; i >= 0 && len(matched) < filter.ReplayLast; i-- {
+			if filter.matches(b.history[i]) {
+				matched = append(matched, b.history[i])
+			}
+		}
+		for i := len(matched) - 1; i >= 0; i-- {
+			select {
+			case s.ch <- matched[i]:
+			default:
+				s.dropped.Add(1)
+			}
+		}
+	}
+	b.m.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.m.Lock()
+			defer b.m.Unlock()
+			if _, ok := b.subscribers[s]; ok {
+				delete(b.subscribers, s)
+				close(s.ch)
+			}
+		})
+	}
+	return s.ch, cancel
+}
+
+// Subscribe returns a stream of typed lifecycle events matching filter,
+// along with a CancelFunc the caller must invoke once done to release the
+// subscription's buffer.
+func (d *daemonImpl) Subscribe(filter EventFilter) (<-chan Event, CancelFunc) {
+	return d.events.subscribe(filter)
+}
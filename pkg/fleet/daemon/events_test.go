@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBusSubscribeReplaysOnlyMatchingInterleavedEvents(t *testing.T) {
+	b := newEventBus()
+
+	b.publish(Event{Kind: EventKindInstallStarted, Package: "datadog-agent"})
+	b.publish(Event{Kind: EventKindCatalogUpdated})
+	b.publish(Event{Kind: EventKindInstallSucceeded, Package: "datadog-agent"})
+	b.publish(Event{Kind: EventKindGarbageCollected})
+	b.publish(Event{Kind: EventKindInstallStarted, Package: "other-package"})
+
+	ch, cancel := b.subscribe(EventFilter{Packages: []string{"datadog-agent"}, ReplayLast: 2})
+	defer cancel()
+
+	var replayed []Event
+	for i := 0; i < 2; i++ {
+		replayed = append(replayed, <-ch)
+	}
+
+	assert.Equal(t, EventKindInstallStarted, replayed[0].Kind)
+	assert.Equal(t, EventKindInstallSucceeded, replayed[1].Kind)
+	for _, e := range replayed {
+		assert.Equal(t, "datadog-agent", e.Package)
+	}
+
+	select {
+	case e := <-ch:
+		t.Fatalf("expected no more replayed events, got %+v", e)
+	default:
+	}
+}
+
+func TestEventBusSubscribeDeliversLiveEventsAfterReplay(t *testing.T) {
+	b := newEventBus()
+	b.publish(Event{Kind: EventKindInstallStarted, Package: "datadog-agent"})
+
+	ch, cancel := b.subscribe(EventFilter{ReplayLast: 1})
+	defer cancel()
+
+	assert.Equal(t, EventKindInstallStarted, (<-ch).Kind)
+
+	b.publish(Event{Kind: EventKindInstallSucceeded, Package: "datadog-agent"})
+	assert.Equal(t, EventKindInstallSucceeded, (<-ch).Kind)
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxRequestRetries bounds how many times a retryable remote API
+	// request is retried before the daemon gives up on it.
+	maxRequestRetries = 5
+	// retryBaseDelay is the delay before the first retry; later retries
+	// back off exponentially from it, capped at retryMaxDelay.
+	retryBaseDelay = 2 * time.Second
+	// retryMaxDelay caps the backoff delay between retries.
+	retryMaxDelay = 2 * time.Minute
+)
+
+// retryTracker counts retry attempts per remote API request ID, so only
+// transient failures get retried, and only up to a bounded number of times.
+// It also gates scheduling a retry's delayed callback against stopAll, so a
+// retry timer firing after the daemon has started stopping can't call back
+// into code that assumes requestsWG.Wait has not yet returned.
+type retryTracker struct {
+	m        sync.Mutex
+	attempts map[string]int
+	timers   []*time.Timer
+	stopped  bool
+}
+
+func newRetryTracker() *retryTracker {
+	return &retryTracker{attempts: make(map[string]int)}
+}
+
+// next returns the delay before the next retry of id and whether a retry
+// should happen at all. It returns false once id has exhausted
+// maxRequestRetries attempts.
+func (t *retryTracker) next(id string) (time.Duration, bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	n := t.attempts[id]
+	if n >= maxRequestRetries {
+		delete(t.attempts, id)
+		return 0, false
+	}
+	t.attempts[id] = n + 1
+
+	delay := retryBaseDelay * time.Duration(1<<uint(n))
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay, true
+}
+
+// forget clears id's retry count, e.g. after it succeeds or is abandoned.
+func (t *retryTracker) forget(id string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	delete(t.attempts, id)
+}
+
+// schedule runs fn after delay, unless stopAll has already been called or is
+// called before the timer fires, in which case fn is never invoked. Use this
+// instead of time.AfterFunc directly for any retry callback that calls back
+// into code relying on the daemon not having started stopping yet.
+func (t *retryTracker) schedule(delay time.Duration, fn func()) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.stopped {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		t.m.Lock()
+		defer t.m.Unlock()
+		if t.stopped {
+			return
+		}
+		fn()
+	})
+	t.timers = append(t.timers, timer)
+}
+
+// stopAll prevents any retry scheduled through schedule from firing from now
+// on - whether still pending or already in its callback - and cancels the
+// ones still pending. Once stopAll returns, no schedule call made before it
+// will invoke its fn after this point, so callers can safely rely on it
+// having run (or never running) before a concurrent requestsWG.Wait unblocks.
+func (t *retryTracker) stopAll() {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.stopped = true
+	for _, timer := range t.timers {
+		timer.Stop()
+	}
+	t.timers = nil
+}
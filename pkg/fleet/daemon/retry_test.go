@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTrackerNextBacksOffAndGivesUp(t *testing.T) {
+	tr := newRetryTracker()
+
+	var delays []time.Duration
+	for i := 0; i < maxRequestRetries; i++ {
+		delay, retry := tr.next("req-1")
+		assert.True(t, retry)
+		delays = append(delays, delay)
+	}
+	for i := 1; i < len(delays); i++ {
+		assert.GreaterOrEqual(t, delays[i], delays[i-1])
+	}
+
+	_, retry := tr.next("req-1")
+	assert.False(t, retry, "request should have exhausted its retries")
+}
+
+func TestRetryTrackerForget(t *testing.T) {
+	tr := newRetryTracker()
+	_, _ = tr.next("req-1")
+	tr.forget("req-1")
+
+	delay, retry := tr.next("req-1")
+	assert.True(t, retry)
+	assert.Equal(t, retryBaseDelay, delay)
+}
+
+func TestRetryTrackerStopAllPreventsScheduledFnFromRunning(t *testing.T) {
+	tr := newRetryTracker()
+	var ran atomic.Bool
+
+	tr.schedule(10*time.Millisecond, func() { ran.Store(true) })
+	tr.stopAll()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.False(t, ran.Load(), "fn must not run once stopAll has returned")
+}
+
+func TestRetryTrackerScheduleAfterStopAllIsNoop(t *testing.T) {
+	tr := newRetryTracker()
+	tr.stopAll()
+
+	var ran atomic.Bool
+	tr.schedule(0, func() { ran.Store(true) })
+	time.Sleep(20 * time.Millisecond)
+
+	assert.False(t, ran.Load())
+}
@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// methodUpgrade is the remote-API method for Daemon.Upgrade.
+const methodUpgrade = "upgrade"
+
+// defaultUpgradeHealthCheckTimeout bounds how long UpgradeOptions.HealthCheck
+// is allowed to run before the upgrade is considered failed.
+const defaultUpgradeHealthCheckTimeout = 1 * time.Minute
+
+// UpgradeStrategy controls how Daemon.Upgrade switches a package to a new
+// version.
+type UpgradeStrategy string
+
+const (
+	// UpgradeStrategyDirect installs the new version in place, with no
+	// experiment phase. Used when the caller already trusts the new version.
+	UpgradeStrategyDirect UpgradeStrategy = "direct"
+	// UpgradeStrategyViaExperiment starts an experiment, runs the health
+	// check, and promotes it to stable on success. This is the default.
+	UpgradeStrategyViaExperiment UpgradeStrategy = "via_experiment"
+	// UpgradeStrategyCanary is like UpgradeStrategyViaExperiment but intended
+	// for callers that gate the health check on a subset of traffic before
+	// promoting.
+	UpgradeStrategyCanary UpgradeStrategy = "canary"
+)
+
+// UpgradeOptions configures a Daemon.Upgrade call.
+type UpgradeOptions struct {
+	// Strategy selects how the new version is rolled out. Defaults to
+	// UpgradeStrategyViaExperiment.
+	Strategy UpgradeStrategy
+	// PreHook, if set, runs before the new version is installed. A non-nil
+	// error aborts the upgrade before anything changes.
+	PreHook func(context.Context) error
+	// PostHook, if set, runs after the new version is live but, for
+	// strategies that go through an experiment, before it is promoted to
+	// stable, so a failure can still be rolled back the same way a
+	// HealthCheck failure is. UpgradeStrategyDirect has no experiment phase
+	// to roll back to, so a PostHook failure there leaves the new version
+	// installed despite Upgrade returning an error.
+	PostHook func(context.Context) error
+	// HealthCheck, if set, is run after switching to the new version for
+	// strategies that go through an experiment. A non-nil error triggers an
+	// automatic rollback to the prior stable version.
+	HealthCheck func(context.Context) error
+	// HealthCheckTimeout bounds HealthCheck. Defaults to
+	// defaultUpgradeHealthCheckTimeout.
+	HealthCheckTimeout time.Duration
+}
+
+// Upgrade performs an in-place upgrade of pkg to the package hosted at url,
+// using the given strategy. Unlike orchestrating StartExperiment, a
+// HealthCheck and PromoteExperiment from the caller, Upgrade runs as a single
+// atomic operation and automatically rolls back to the prior stable version
+// if the post-switch health check fails.
+func (d *daemonImpl) Upgrade(ctx context.Context, pkg string, url string, opts UpgradeOptions) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.upgrade(ctx, pkg, url, opts)
+}
+
+func (d *daemonImpl) upgrade(ctx context.Context, pkg string, url string, opts UpgradeOptions) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "upgrade")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	d.refreshState(ctx)
+	defer d.refreshState(ctx)
+
+	if opts.Strategy == "" {
+		opts.Strategy = UpgradeStrategyViaExperiment
+	}
+	if opts.Strategy == UpgradeStrategyCanary && !installer.CapabilitiesOf(d.installer).SupportsCanary {
+		return fmt.Errorf("upgrade strategy %s is not supported by the configured installer backend", opts.Strategy)
+	}
+	if opts.PreHook != nil {
+		if err = opts.PreHook(ctx); err != nil {
+			return fmt.Errorf("upgrade pre-hook failed: %w", err)
+		}
+	}
+
+	log.Infof("Daemon: Upgrading package %s to %s using %s strategy", pkg, url, opts.Strategy)
+
+	switch opts.Strategy {
+	case UpgradeStrategyDirect:
+		// d.install publishes its own InstallStarted/Succeeded/Failed
+		// events; upgrade must not publish them a second time.
+		if err = d.install(ctx, pkg, url); err != nil {
+			return fmt.Errorf("could not upgrade %s: %w", pkg, err)
+		}
+		if opts.PostHook != nil {
+			if err = opts.PostHook(ctx); err != nil {
+				return fmt.Errorf("upgrade post-hook failed after %s was already installed (direct strategy has no rollback path): %w", pkg, err)
+			}
+		}
+	case UpgradeStrategyViaExperiment, UpgradeStrategyCanary:
+		// upgradeViaExperiment publishes its own experiment lifecycle
+		// events, and only promotes once HealthCheck and PostHook have both
+		// succeeded, so a PostHook failure rolls back the same as a
+		// HealthCheck failure would.
+		if err = d.upgradeViaExperiment(ctx, pkg, url, opts); err != nil {
+			return fmt.Errorf("could not upgrade %s: %w", pkg, err)
+		}
+	default:
+		return fmt.Errorf("unknown upgrade strategy: %s", opts.Strategy)
+	}
+
+	log.Infof("Daemon: Successfully upgraded package %s to %s", pkg, url)
+	return nil
+}
+
+// upgradeViaExperiment starts an experiment for url, runs opts.HealthCheck
+// and opts.PostHook against it, and either promotes it to stable or rolls it
+// back by removing the experiment, restoring the prior stable version. Both
+// checks run before promotion so either one failing can still be rolled
+// back; once promoted there is no prior experiment left to roll back to.
+func (d *daemonImpl) upgradeViaExperiment(ctx context.Context, pkg string, url string, opts UpgradeOptions) error {
+	if err := d.startExperiment(ctx, pkg, url); err != nil {
+		return fmt.Errorf("could not start experiment: %w", err)
+	}
+
+	if opts.HealthCheck != nil {
+		timeout := opts.HealthCheckTimeout
+		if timeout <= 0 {
+			timeout = defaultUpgradeHealthCheckTimeout
+		}
+		hcCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := opts.HealthCheck(hcCtx)
+		cancel()
+		if err != nil {
+			return d.rollbackExperiment(ctx, pkg, fmt.Errorf("health check failed after upgrade: %w", err))
+		}
+	}
+
+	if opts.PostHook != nil {
+		if err := opts.PostHook(ctx); err != nil {
+			return d.rollbackExperiment(ctx, pkg, fmt.Errorf("upgrade post-hook failed before promotion: %w", err))
+		}
+	}
+
+	return d.promoteExperiment(ctx, pkg)
+}
+
+// rollbackExperiment stops the in-progress experiment for pkg in response to
+// cause and returns an error describing both, so a HealthCheck or PostHook
+// failure reported by upgrade always matches the state it leaves behind.
+func (d *daemonImpl) rollbackExperiment(ctx context.Context, pkg string, cause error) error {
+	log.Warnf("Daemon: rolling back upgrade of %s to prior stable: %v", pkg, cause)
+	if rollbackErr := d.stopExperiment(ctx, pkg); rollbackErr != nil {
+		return fmt.Errorf("%w; rollback also failed: %v", cause, rollbackErr)
+	}
+	return fmt.Errorf("%w (rolled back to prior stable)", cause)
+}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRC is a no-op rcClient: none of the scenarios below go through remote
+// config, they only need a receiver refreshState can call into.
+type fakeRC struct{}
+
+func (fakeRC) Start(func(catalog) error, func(remoteAPIRequest) error) {}
+func (fakeRC) Close()                                                  {}
+func (fakeRC) SetState([]*pbgo.PackageState)                           {}
+
+func newTestDaemon(t *testing.T) *daemonImpl {
+	inst, err := installer.NewBackend(installer.BackendNoop, nil, "")
+	require.NoError(t, err)
+	return &daemonImpl{
+		installer: inst,
+		rc:        fakeRC{},
+		events:    newEventBus(),
+		retries:   newRetryTracker(),
+	}
+}
+
+const (
+	testPkg        = "datadog-agent"
+	testStableURL  = "oci://registry/datadog-agent:1.0.0"
+	testUpgradeURL = "oci://registry/datadog-agent:2.0.0"
+)
+
+func TestUpgradeDirectSuccess(t *testing.T) {
+	d := newTestDaemon(t)
+	require.NoError(t, d.Install(context.Background(), testStableURL))
+
+	err := d.Upgrade(context.Background(), testPkg, testUpgradeURL, UpgradeOptions{Strategy: UpgradeStrategyDirect})
+	require.NoError(t, err)
+
+	s, err := d.installer.State(testPkg)
+	require.NoError(t, err)
+	assert.Equal(t, testUpgradeURL, s.Stable)
+	assert.Empty(t, s.Experiment)
+}
+
+func TestUpgradeDirectPostHookFailureLeavesNewVersionInstalled(t *testing.T) {
+	d := newTestDaemon(t)
+	require.NoError(t, d.Install(context.Background(), testStableURL))
+
+	postHookErr := fmt.Errorf("post-hook boom")
+	err := d.Upgrade(context.Background(), testPkg, testUpgradeURL, UpgradeOptions{
+		Strategy: UpgradeStrategyDirect,
+		PostHook: func(context.Context) error { return postHookErr },
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, postHookErr)
+
+	// Direct strategy has no experiment phase to roll back to, so the new
+	// version is already live despite Upgrade returning an error.
+	s, err := d.installer.State(testPkg)
+	require.NoError(t, err)
+	assert.Equal(t, testUpgradeURL, s.Stable)
+}
+
+func TestUpgradeViaExperimentHealthCheckFailureRollsBack(t *testing.T) {
+	d := newTestDaemon(t)
+	require.NoError(t, d.Install(context.Background(), testStableURL))
+
+	healthCheckErr := fmt.Errorf("health check boom")
+	err := d.Upgrade(context.Background(), testPkg, testUpgradeURL, UpgradeOptions{
+		Strategy:    UpgradeStrategyViaExperiment,
+		HealthCheck: func(context.Context) error { return healthCheckErr },
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, healthCheckErr)
+
+	s, err := d.installer.State(testPkg)
+	require.NoError(t, err)
+	assert.Equal(t, testStableURL, s.Stable, "rollback must restore the prior stable version")
+	assert.Empty(t, s.Experiment, "rollback must clear the experiment")
+}
+
+func TestUpgradeViaExperimentPostHookFailureRollsBackBeforePromotion(t *testing.T) {
+	d := newTestDaemon(t)
+	require.NoError(t, d.Install(context.Background(), testStableURL))
+
+	postHookErr := fmt.Errorf("post-hook boom")
+	err := d.Upgrade(context.Background(), testPkg, testUpgradeURL, UpgradeOptions{
+		Strategy:    UpgradeStrategyViaExperiment,
+		HealthCheck: func(context.Context) error { return nil },
+		PostHook:    func(context.Context) error { return postHookErr },
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, postHookErr)
+
+	s, err := d.installer.State(testPkg)
+	require.NoError(t, err)
+	assert.Equal(t, testStableURL, s.Stable, "a PostHook failure must roll back before promotion")
+	assert.Empty(t, s.Experiment)
+}
+
+func TestUpgradePreHookAbortLeavesNoSideEffects(t *testing.T) {
+	d := newTestDaemon(t)
+	require.NoError(t, d.Install(context.Background(), testStableURL))
+
+	preHookErr := fmt.Errorf("pre-hook boom")
+	err := d.Upgrade(context.Background(), testPkg, testUpgradeURL, UpgradeOptions{
+		PreHook: func(context.Context) error { return preHookErr },
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, preHookErr)
+
+	s, err := d.installer.State(testPkg)
+	require.NoError(t, err)
+	assert.Equal(t, testStableURL, s.Stable, "a PreHook abort must not touch the installer")
+	assert.Empty(t, s.Experiment)
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// walFileName is the append-only log of in-flight remote API requests,
+	// stored under the installer's run dir.
+	walFileName = "requests.wal"
+	// walCompactThreshold is the file size past which the WAL is compacted
+	// down to just its still-pending entries on the next append.
+	walCompactThreshold = 4 << 20 // 4 MiB
+)
+
+// walRecord is a single entry appended to the WAL: either a request that is
+// about to be executed, or a marker that a previously appended request has
+// finished (successfully, invalidly, or with an error) and can be forgotten.
+type walRecord struct {
+	Request remoteAPIRequest `json:"request"`
+	Done    bool             `json:"done"`
+}
+
+// requestWAL is a simple append-only JSON-lines write-ahead log of in-flight
+// remote API requests. It exists so a daemon restart mid-request doesn't
+// leave RC with a task stuck in RUNNING forever: on Start, pending entries
+// are read back and either resumed or failed explicitly.
+type requestWAL struct {
+	m    sync.Mutex
+	path string
+}
+
+func newRequestWAL(runDir string) (*requestWAL, error) {
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create installer run dir %s: %w", runDir, err)
+	}
+	return &requestWAL{path: filepath.Join(runDir, walFileName)}, nil
+}
+
+// append records that request is about to be executed. It must be called
+// before the request's side effects start, so a crash mid-execution leaves
+// behind a pending entry to replay.
+func (w *requestWAL) append(request remoteAPIRequest) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.appendRecordLocked(walRecord{Request: request}); err != nil {
+		return err
+	}
+	return w.compactIfNeededLocked()
+}
+
+// complete marks request id as finished, so it is no longer replayed after a
+// restart. The full request isn't needed: only its ID is used to match the
+// pending entry written by append.
+func (w *requestWAL) complete(id string) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.appendRecordLocked(walRecord{Request: remoteAPIRequest{ID: id}, Done: true})
+}
+
+func (w *requestWAL) appendRecordLocked(rec walRecord) error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open WAL %s: %w", w.path, err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(rec); err != nil {
+		return fmt.Errorf("could not append to WAL: %w", err)
+	}
+	return nil
+}
+
+// pending returns the requests that were appended but never marked
+// complete, in the order they were first seen, for replay on Start.
+func (w *requestWAL) pending() ([]remoteAPIRequest, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	order, byID, err := w.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]remoteAPIRequest, 0, len(byID))
+	for _, id := range order {
+		if rec, ok := byID[id]; ok {
+			requests = append(requests, rec.Request)
+		}
+	}
+	return requests, nil
+}
+
+// readLocked replays the log into an ordered list of IDs and the latest
+// non-done record seen for each still-pending ID.
+func (w *requestWAL) readLocked() ([]string, map[string]walRecord, error) {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, map[string]walRecord{}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open WAL %s: %w", w.path, err)
+	}
+	defer f.Close()
+
+	var order []string
+	seen := map[string]struct{}{}
+	byID := map[string]walRecord{}
+	dec := json.NewDecoder(f)
+	for {
+		var rec walRecord
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, nil, fmt.Errorf("could not decode WAL entry: %w", err)
+		}
+		if _, ok := seen[rec.Request.ID]; !ok {
+			seen[rec.Request.ID] = struct{}{}
+			order = append(order, rec.Request.ID)
+		}
+		if rec.Done {
+			delete(byID, rec.Request.ID)
+		} else {
+			byID[rec.Request.ID] = rec
+		}
+	}
+	return order, byID, nil
+}
+
+// compactIfNeededLocked rewrites the WAL to contain only its still-pending
+// entries once it grows past walCompactThreshold, bounding its size instead
+// of growing forever across a long-lived daemon.
+func (w *requestWAL) compactIfNeededLocked() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return fmt.Errorf("could not stat WAL %s: %w", w.path, err)
+	}
+	if info.Size() < walCompactThreshold {
+		return nil
+	}
+
+	order, byID, err := w.readLocked()
+	if err != nil {
+		return err
+	}
+	tmpPath := w.path + ".compact"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create compacted WAL: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, id := range order {
+		if rec, ok := byID[id]; ok {
+			if err := enc.Encode(rec); err != nil {
+				f.Close()
+				return fmt.Errorf("could not write compacted WAL entry: %w", err)
+			}
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close compacted WAL: %w", err)
+	}
+	return os.Rename(tmpPath, w.path)
+}
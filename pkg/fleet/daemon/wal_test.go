@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestWALAppendPendingComplete(t *testing.T) {
+	wal, err := newRequestWAL(t.TempDir())
+	require.NoError(t, err)
+
+	a := remoteAPIRequest{ID: "a", Package: "datadog-agent"}
+	b := remoteAPIRequest{ID: "b", Package: "datadog-agent"}
+
+	require.NoError(t, wal.append(a))
+	require.NoError(t, wal.append(b))
+
+	pending, err := wal.pending()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []remoteAPIRequest{a, b}, pending)
+
+	require.NoError(t, wal.complete(a.ID))
+
+	pending, err = wal.pending()
+	require.NoError(t, err)
+	assert.Equal(t, []remoteAPIRequest{b}, pending)
+}
+
+func TestRequestWALPendingOnEmptyFile(t *testing.T) {
+	wal, err := newRequestWAL(t.TempDir())
+	require.NoError(t, err)
+
+	pending, err := wal.pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+)
+
+// BackendName identifies a registered Installer backend implementation, as
+// configured through `updater.backend`.
+type BackendName string
+
+const (
+	// BackendExec shells out to the installer binary for every operation.
+	// This is the default backend and remains so for compatibility.
+	BackendExec BackendName = "exec"
+	// BackendOCI is the in-process OCI backend that does not shell out to the
+	// installer binary. It does not resolve manifests or fetch layers: its
+	// Install and InstallExperiment always return an error.
+	BackendOCI BackendName = "oci"
+	// BackendContainerd builds the same backend as BackendOCI; it does not
+	// use a local containerd content store.
+	BackendContainerd BackendName = "containerd"
+	// BackendNoop performs no real installs and only records state in
+	// memory; it rejects installing a URL that is already the stable (or
+	// experiment) version with a Conflict error. It exists so callers can
+	// exercise the daemon in-process (e.g. in tests) without a real installer
+	// binary or registry.
+	BackendNoop BackendName = "noop"
+)
+
+// DefaultBackend is used when `updater.backend` is unset.
+const DefaultBackend = BackendExec
+
+// Factory builds an Installer backend from the daemon's config and the
+// resolved path to the installer binary. installerBin is only meaningful to
+// backends that shell out; in-process backends may ignore it.
+type Factory func(cfg config.Reader, installerBin string) (Installer, error)
+
+// Capabilities describes the optional features a backend supports, so
+// callers can gate behavior - such as which Daemon.Upgrade strategies are
+// available - on what the configured backend actually implements.
+type Capabilities struct {
+	// SupportsCanary reports whether the backend can run a canary upgrade.
+	SupportsCanary bool
+	// SupportsSignatureVerification reports whether the backend verifies
+	// artifact signatures (e.g. cosign) before installing them.
+	SupportsSignatureVerification bool
+	// SupportsLayerCaching reports whether the backend caches package
+	// layers across installs instead of re-fetching them every time.
+	SupportsLayerCaching bool
+}
+
+// CapableInstaller is implemented by backends that can report their
+// Capabilities. Backends that don't implement it are assumed to support
+// none of the optional features.
+type CapableInstaller interface {
+	BackendCapabilities() Capabilities
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[BackendName]Factory{}
+)
+
+// RegisterBackend adds a backend factory to the registry under name,
+// overwriting any previous registration. It is typically called from an
+// init() in the package implementing the backend.
+func RegisterBackend(name BackendName, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// NewBackend builds the Installer backend registered under name.
+func NewBackend(name BackendName, cfg config.Reader, installerBin string) (Installer, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown installer backend %q", name)
+	}
+	return factory(cfg, installerBin)
+}
+
+// CapabilitiesOf returns i's reported Capabilities, or the zero value if i
+// does not implement CapableInstaller.
+func CapabilitiesOf(i Installer) Capabilities {
+	if c, ok := i.(CapableInstaller); ok {
+		return c.BackendCapabilities()
+	}
+	return Capabilities{}
+}
+
+// PackageNameFromURL extracts the package name a backend should key its
+// state by from a package artifact URL or OCI reference, e.g.
+// "oci://registry/datadog-agent-package:7.50.0" and
+// "https://example.com/datadog-agent-package@sha256:abc" both yield
+// "datadog-agent-package". Install and InstallExperiment only receive a URL,
+// not the package name the rest of the Installer interface keys state by, so
+// backends derive it here instead of keying state by the raw URL.
+func PackageNameFromURL(url string) string {
+	name := url
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if idx := strings.IndexByte(name, '@'); idx >= 0 {
+		name = name[:idx]
+	}
+	if idx := strings.IndexByte(name, ':'); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
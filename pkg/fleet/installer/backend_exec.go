@@ -0,0 +1,24 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/config/utils"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/exec"
+)
+
+func init() {
+	RegisterBackend(BackendExec, newExecBackend)
+}
+
+func newExecBackend(cfg config.Reader, installerBin string) (Installer, error) {
+	registry := cfg.GetString("updater.registry")
+	registryAuth := cfg.GetString("updater.registry_auth")
+	apiKey := utils.SanitizeAPIKey(cfg.GetString("api_key"))
+	site := cfg.GetString("site")
+	return exec.NewInstallerExec(installerBin, registry, registryAuth, apiKey, site), nil
+}
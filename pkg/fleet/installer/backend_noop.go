@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+)
+
+func init() {
+	RegisterBackend(BackendNoop, newNoopBackend)
+}
+
+// noopInstaller is an in-process Installer that performs no real installs.
+// It exists so the daemon can be exercised in tests without a real installer
+// binary, registry, or filesystem layout.
+type noopInstaller struct {
+	m      sync.Mutex
+	states map[string]repository.State
+}
+
+func newNoopBackend(_ config.Reader, _ string) (Installer, error) {
+	return &noopInstaller{states: make(map[string]repository.State)}, nil
+}
+
+func (n *noopInstaller) Install(_ context.Context, url string) error {
+	pkg := PackageNameFromURL(url)
+	n.m.Lock()
+	defer n.m.Unlock()
+	s := n.states[pkg]
+	if s.Stable == url {
+		return installerErrors.Conflict(fmt.Errorf("%s is already installed at %s", pkg, url))
+	}
+	s.Stable = url
+	n.states[pkg] = s
+	return nil
+}
+
+func (n *noopInstaller) InstallExperiment(_ context.Context, url string) error {
+	pkg := PackageNameFromURL(url)
+	n.m.Lock()
+	defer n.m.Unlock()
+	s := n.states[pkg]
+	if s.Experiment == url {
+		return installerErrors.Conflict(fmt.Errorf("an experiment for %s at %s is already in progress", pkg, url))
+	}
+	s.Experiment = url
+	n.states[pkg] = s
+	return nil
+}
+
+func (n *noopInstaller) PromoteExperiment(_ context.Context, pkg string) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+	s := n.states[pkg]
+	s.Stable = s.Experiment
+	s.Experiment = ""
+	n.states[pkg] = s
+	return nil
+}
+
+func (n *noopInstaller) RemoveExperiment(_ context.Context, pkg string) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+	s := n.states[pkg]
+	s.Experiment = ""
+	n.states[pkg] = s
+	return nil
+}
+
+func (n *noopInstaller) GarbageCollect(_ context.Context) error {
+	return nil
+}
+
+func (n *noopInstaller) State(pkg string) (repository.State, error) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	return n.states[pkg], nil
+}
+
+func (n *noopInstaller) States() (map[string]repository.State, error) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	out := make(map[string]repository.State, len(n.states))
+	for k, v := range n.states {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// BackendCapabilities reports that the noop backend supports none of the
+// optional backend features.
+func (n *noopInstaller) BackendCapabilities() Capabilities {
+	return Capabilities{}
+}
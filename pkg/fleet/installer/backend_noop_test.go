@@ -0,0 +1,80 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"context"
+	"testing"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoopInstallerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	backend, err := newNoopBackend(nil, "")
+	require.NoError(t, err)
+	n := backend.(*noopInstaller)
+
+	url := "oci://registry/datadog-agent-package:7.50.0"
+	pkg := PackageNameFromURL(url)
+	require.Equal(t, "datadog-agent-package", pkg)
+
+	require.NoError(t, n.Install(ctx, url))
+	s, err := n.State(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, url, s.Stable)
+	assert.Empty(t, s.Experiment)
+
+	experimentURL := "oci://registry/datadog-agent-package:7.51.0"
+	require.NoError(t, n.InstallExperiment(ctx, experimentURL))
+	s, err = n.State(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, url, s.Stable)
+	assert.Equal(t, experimentURL, s.Experiment)
+
+	require.NoError(t, n.PromoteExperiment(ctx, pkg))
+	s, err = n.State(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, experimentURL, s.Stable)
+	assert.Empty(t, s.Experiment)
+
+	require.NoError(t, n.InstallExperiment(ctx, url))
+	require.NoError(t, n.RemoveExperiment(ctx, pkg))
+	s, err = n.State(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, experimentURL, s.Stable)
+	assert.Empty(t, s.Experiment)
+
+	states, err := n.States()
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+	assert.Contains(t, states, pkg)
+}
+
+func TestNoopInstallerInstallConflict(t *testing.T) {
+	ctx := context.Background()
+	backend, err := newNoopBackend(nil, "")
+	require.NoError(t, err)
+	n := backend.(*noopInstaller)
+
+	url := "oci://registry/datadog-agent-package:7.50.0"
+	require.NoError(t, n.Install(ctx, url))
+
+	err = n.Install(ctx, url)
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeConflict, ie.Code())
+
+	require.NoError(t, n.InstallExperiment(ctx, url))
+	err = n.InstallExperiment(ctx, url)
+	require.Error(t, err)
+	ie, ok = installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeConflict, ie.Code())
+}
@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package errors defines a typed error surface for installer operations, so
+// daemon methods, the remote-API request handler, and (eventually) an HTTP
+// surface can all classify a failure the same way instead of pattern
+// matching on error strings.
+package errors
+
+import (
+	stderrors "errors"
+)
+
+// ErrorCode classifies an InstallerError for callers that need a stable,
+// serializable value (e.g. to report over RC) rather than a type switch.
+type ErrorCode uint64
+
+const (
+	// ErrCodeUnknown is used for errors that could not be classified into
+	// any of the categories below.
+	ErrCodeUnknown ErrorCode = iota
+	// ErrCodeConflict means the operation could not proceed because of the
+	// installer's current state (e.g. the requested version is already
+	// installed, or an experiment is already in progress).
+	ErrCodeConflict
+	// ErrCodeNotFound means a referenced package, version, or experiment
+	// does not exist.
+	ErrCodeNotFound
+	// ErrCodeForbidden means the operation is not permitted (e.g. signature
+	// verification failed, or the package is not allow-listed).
+	ErrCodeForbidden
+	// ErrCodeInvalidParameter means the caller supplied a malformed or
+	// unsupported argument.
+	ErrCodeInvalidParameter
+	// ErrCodeUnavailable means a dependency the operation needs (the
+	// registry, the network, a lock held by another process) is
+	// transiently unavailable. Unavailable errors are retryable.
+	ErrCodeUnavailable
+	// ErrCodeSystem means the operation failed for a reason outside the
+	// installer's control (disk full, permission denied on the filesystem).
+	ErrCodeSystem
+)
+
+// InstallerError is the concrete, typed error returned by installer
+// operations. Callers that only care about a code can use Code(); callers
+// that want to test for a specific category should use the package-level
+// Is* interfaces with errors.As instead, since those also match errors that
+// implement the same interface without being an *InstallerError.
+type InstallerError struct {
+	code ErrorCode
+	err  error
+}
+
+// Error implements the error interface.
+func (e *InstallerError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap allows errors.Is/errors.As to see through to the underlying error.
+func (e *InstallerError) Unwrap() error {
+	return e.err
+}
+
+// Code returns e's classification.
+func (e *InstallerError) Code() ErrorCode {
+	return e.code
+}
+
+// IsConflict reports whether e is an ErrCodeConflict error.
+func (e *InstallerError) IsConflict() bool { return e.code == ErrCodeConflict }
+
+// IsNotFound reports whether e is an ErrCodeNotFound error.
+func (e *InstallerError) IsNotFound() bool { return e.code == ErrCodeNotFound }
+
+// IsForbidden reports whether e is an ErrCodeForbidden error.
+func (e *InstallerError) IsForbidden() bool { return e.code == ErrCodeForbidden }
+
+// IsInvalidParameter reports whether e is an ErrCodeInvalidParameter error.
+func (e *InstallerError) IsInvalidParameter() bool { return e.code == ErrCodeInvalidParameter }
+
+// IsUnavailable reports whether e is an ErrCodeUnavailable error.
+func (e *InstallerError) IsUnavailable() bool { return e.code == ErrCodeUnavailable }
+
+// IsSystem reports whether e is an ErrCodeSystem error.
+func (e *InstallerError) IsSystem() bool { return e.code == ErrCodeSystem }
+
+// IsRetryable reports whether retrying the operation that produced e might
+// succeed without caller intervention. Only transient, environment-level
+// failures are retryable; user errors like a bad parameter or a conflicting
+// state are not.
+func (e *InstallerError) IsRetryable() bool {
+	return e.code == ErrCodeUnavailable
+}
+
+// Conflict wraps err as an ErrCodeConflict InstallerError.
+func Conflict(err error) *InstallerError { return &InstallerError{code: ErrCodeConflict, err: err} }
+
+// NotFound wraps err as an ErrCodeNotFound InstallerError.
+func NotFound(err error) *InstallerError { return &InstallerError{code: ErrCodeNotFound, err: err} }
+
+// Forbidden wraps err as an ErrCodeForbidden InstallerError.
+func Forbidden(err error) *InstallerError { return &InstallerError{code: ErrCodeForbidden, err: err} }
+
+// InvalidParameter wraps err as an ErrCodeInvalidParameter InstallerError.
+func InvalidParameter(err error) *InstallerError {
+	return &InstallerError{code: ErrCodeInvalidParameter, err: err}
+}
+
+// Unavailable wraps err as an ErrCodeUnavailable InstallerError.
+func Unavailable(err error) *InstallerError {
+	return &InstallerError{code: ErrCodeUnavailable, err: err}
+}
+
+// System wraps err as an ErrCodeSystem InstallerError.
+func System(err error) *InstallerError { return &InstallerError{code: ErrCodeSystem, err: err} }
+
+// IsConflict is implemented by errors that represent a conflict with the
+// installer's current state.
+type IsConflict interface{ IsConflict() bool }
+
+// IsNotFound is implemented by errors that represent a missing package,
+// version, or experiment.
+type IsNotFound interface{ IsNotFound() bool }
+
+// IsForbidden is implemented by errors that represent a disallowed
+// operation.
+type IsForbidden interface{ IsForbidden() bool }
+
+// IsInvalidParameter is implemented by errors that represent a malformed or
+// unsupported caller argument.
+type IsInvalidParameter interface{ IsInvalidParameter() bool }
+
+// IsUnavailable is implemented by errors that represent a transiently
+// unavailable dependency.
+type IsUnavailable interface{ IsUnavailable() bool }
+
+// IsSystem is implemented by errors that represent a failure outside the
+// installer's control.
+type IsSystem interface{ IsSystem() bool }
+
+// IsRetryable is implemented by errors that know whether retrying the
+// operation that produced them might succeed.
+type IsRetryable interface{ IsRetryable() bool }
+
+// causer is the github.com/pkg/errors convention for exposing the error
+// that was wrapped, predating Go's own errors.Unwrap.
+type causer interface{ Cause() error }
+
+// AsInstallerError walks err's cause chain - following both stdlib Unwrap()
+// and pkg/errors-style Cause() - looking for an *InstallerError or any error
+// implementing one of the Is* interfaces above, and returns a classified
+// InstallerError for it. A type match on *InstallerError takes precedence
+// over interface classification at the same step of the chain.
+func AsInstallerError(err error) (*InstallerError, bool) {
+	for cur := err; cur != nil; cur = unwrap(cur) {
+		var ie *InstallerError
+		if stderrors.As(cur, &ie) {
+			return ie, true
+		}
+		if code, ok := classify(cur); ok {
+			return &InstallerError{code: code, err: err}, true
+		}
+	}
+	return nil, false
+}
+
+// classify checks err against each Is* interface in turn, rather than a type
+// switch: a type switch picks the first case err's concrete type satisfies
+// and never falls through, so a single error type implementing more than one
+// Is* interface - exactly the pattern this package invites - could match a
+// case whose method returns false and be classified as ErrCodeUnknown even
+// though a later interface's method returns true.
+func classify(err error) (ErrorCode, bool) {
+	if e, ok := err.(IsConflict); ok && e.IsConflict() {
+		return ErrCodeConflict, true
+	}
+	if e, ok := err.(IsNotFound); ok && e.IsNotFound() {
+		return ErrCodeNotFound, true
+	}
+	if e, ok := err.(IsForbidden); ok && e.IsForbidden() {
+		return ErrCodeForbidden, true
+	}
+	if e, ok := err.(IsInvalidParameter); ok && e.IsInvalidParameter() {
+		return ErrCodeInvalidParameter, true
+	}
+	if e, ok := err.(IsUnavailable); ok && e.IsUnavailable() {
+		return ErrCodeUnavailable, true
+	}
+	if e, ok := err.(IsSystem); ok && e.IsSystem() {
+		return ErrCodeSystem, true
+	}
+	return ErrCodeUnknown, false
+}
+
+func unwrap(err error) error {
+	if c, ok := err.(causer); ok {
+		return c.Cause()
+	}
+	return stderrors.Unwrap(err)
+}
+
+// From classifies err into an *InstallerError, defaulting to ErrCodeUnknown
+// when it doesn't match any known category. It returns nil for a nil err.
+func From(err error) *InstallerError {
+	if err == nil {
+		return nil
+	}
+	if ie, ok := AsInstallerError(err); ok {
+		return ie
+	}
+	return &InstallerError{code: ErrCodeUnknown, err: err}
+}
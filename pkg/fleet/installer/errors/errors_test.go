@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstructorsSetExpectedCode(t *testing.T) {
+	cause := fmt.Errorf("boom")
+	tests := []struct {
+		name string
+		err  *InstallerError
+		code ErrorCode
+	}{
+		{"Conflict", Conflict(cause), ErrCodeConflict},
+		{"NotFound", NotFound(cause), ErrCodeNotFound},
+		{"Forbidden", Forbidden(cause), ErrCodeForbidden},
+		{"InvalidParameter", InvalidParameter(cause), ErrCodeInvalidParameter},
+		{"Unavailable", Unavailable(cause), ErrCodeUnavailable},
+		{"System", System(cause), ErrCodeSystem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, tt.err.Code())
+			assert.ErrorIs(t, tt.err, cause)
+			assert.Equal(t, cause.Error(), tt.err.Error())
+		})
+	}
+}
+
+func TestIsRetryableOnlyForUnavailable(t *testing.T) {
+	assert.True(t, Unavailable(fmt.Errorf("x")).IsRetryable())
+	assert.False(t, Conflict(fmt.Errorf("x")).IsRetryable())
+	assert.False(t, System(fmt.Errorf("x")).IsRetryable())
+}
+
+// multiIs implements more than one Is* interface, with only one of its
+// methods returning true. A type switch over the Is* interfaces would match
+// whichever case is listed first that multiIs satisfies - regardless of what
+// that method returns - and misclassify it; classify must not make that
+// mistake.
+type multiIs struct{}
+
+func (multiIs) Error() string            { return "multi" }
+func (multiIs) IsConflict() bool         { return false }
+func (multiIs) IsNotFound() bool         { return false }
+func (multiIs) IsForbidden() bool        { return false }
+func (multiIs) IsInvalidParameter() bool { return false }
+func (multiIs) IsUnavailable() bool      { return true }
+func (multiIs) IsSystem() bool           { return false }
+
+func TestClassifyChecksEachInterfaceIndependently(t *testing.T) {
+	code, ok := classify(multiIs{})
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeUnavailable, code)
+}
+
+func TestAsInstallerErrorClassifiesMultiInterfaceError(t *testing.T) {
+	ie, ok := AsInstallerError(multiIs{})
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeUnavailable, ie.Code())
+}
+
+func TestAsInstallerErrorPrefersConcreteTypeOverInterface(t *testing.T) {
+	wrapped := fmt.Errorf("wrapping: %w", Conflict(fmt.Errorf("already installed")))
+	ie, ok := AsInstallerError(wrapped)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeConflict, ie.Code())
+}
+
+type causerError struct{ cause error }
+
+func (c causerError) Error() string { return "causer: " + c.cause.Error() }
+func (c causerError) Cause() error  { return c.cause }
+
+func TestAsInstallerErrorWalksPkgErrorsCauseChain(t *testing.T) {
+	wrapped := causerError{cause: NotFound(fmt.Errorf("no such version"))}
+	ie, ok := AsInstallerError(wrapped)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeNotFound, ie.Code())
+}
+
+func TestAsInstallerErrorNoMatch(t *testing.T) {
+	_, ok := AsInstallerError(stderrors.New("plain"))
+	assert.False(t, ok)
+}
+
+func TestFromClassifiesOrDefaultsToUnknown(t *testing.T) {
+	assert.Nil(t, From(nil))
+
+	ie := From(Forbidden(fmt.Errorf("nope")))
+	assert.Equal(t, ErrCodeForbidden, ie.Code())
+
+	ie = From(stderrors.New("plain"))
+	assert.Equal(t, ErrCodeUnknown, ie.Code())
+}
@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package oci
+
+import (
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+)
+
+func init() {
+	installer.RegisterBackend(installer.BackendContainerd, newContainerdBackend)
+}
+
+// newContainerdBackend builds the same OCI-native backend as BackendOCI.
+// It does not talk to a local containerd content store: resolution and
+// fetching behave exactly as for BackendOCI.
+func newContainerdBackend(cfg config.Reader, installerBin string) (installer.Installer, error) {
+	return newBackend(cfg, installerBin)
+}
@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package oci
+
+import (
+	"context"
+	"testing"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBackend builds a backend directly rather than through newBackend,
+// since the latter needs a config.Reader this package has no fake for.
+func newTestBackend() *backend {
+	return &backend{states: make(map[string]repository.State)}
+}
+
+func TestInstallFailsWithClassifiedErrorWhenResolveIsNotImplemented(t *testing.T) {
+	b := newTestBackend()
+
+	err := b.Install(context.Background(), "registry/repo:tag")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeSystem, ie.Code())
+}
+
+func TestInstallExperimentFailsWithClassifiedErrorWhenResolveIsNotImplemented(t *testing.T) {
+	b := newTestBackend()
+
+	err := b.InstallExperiment(context.Background(), "registry/repo:tag")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeSystem, ie.Code())
+}
+
+func TestSetStableReturnsConflictForAlreadyInstalledDigest(t *testing.T) {
+	b := newTestBackend()
+	require.NoError(t, b.setStable("repo", "sha256:deadbeef"))
+
+	err := b.setStable("repo", "sha256:deadbeef")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeConflict, ie.Code())
+}
+
+func TestSetExperimentReturnsConflictForAlreadyInProgressDigest(t *testing.T) {
+	b := newTestBackend()
+	require.NoError(t, b.setExperiment("repo", "sha256:deadbeef"))
+
+	err := b.setExperiment("repo", "sha256:deadbeef")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeConflict, ie.Code())
+}
+
+func TestBackendCapabilitiesReportsNoneYet(t *testing.T) {
+	b := newTestBackend()
+	caps := b.BackendCapabilities()
+	assert.False(t, caps.SupportsSignatureVerification)
+	assert.False(t, caps.SupportsLayerCaching)
+}
@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDockerConfigAuthUnsetPathIsAnonymous(t *testing.T) {
+	auth, err := loadDockerConfigAuth("")
+	require.NoError(t, err)
+	assert.Empty(t, auth.registries)
+}
+
+func TestLoadDockerConfigAuthMissingFileIsAnonymous(t *testing.T) {
+	auth, err := loadDockerConfigAuth(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	require.NoError(t, err)
+	assert.Empty(t, auth.registries)
+}
+
+func TestLoadDockerConfigAuthMalformedJSONErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := loadDockerConfigAuth(path)
+	require.Error(t, err)
+}
+
+func TestLoadDockerConfigAuthParsesRegistries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`), 0o600))
+
+	auth, err := loadDockerConfigAuth(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"registry.example.com": "dXNlcjpwYXNz"}, auth.registries)
+}
+
+func TestResolveUnavailableWhenAuthCannotBeLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := resolve(context.Background(), "registry/repo:tag", path)
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeUnavailable, ie.Code())
+}
+
+func TestResolveSystemErrorWhenNotImplemented(t *testing.T) {
+	_, err := resolve(context.Background(), "registry/repo:tag", "")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeSystem, ie.Code())
+}
+
+func TestVerifySignatureForbidden(t *testing.T) {
+	err := verifySignature(context.Background(), resolvedRef{ref: "registry/repo:tag"})
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeForbidden, ie.Code())
+}
+
+func TestFetchAndUnpackInvalidParameterWhenStorePathMissing(t *testing.T) {
+	err := fetchAndUnpack(context.Background(), resolvedRef{}, "")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeInvalidParameter, ie.Code())
+}
+
+func TestFetchAndUnpackSystemErrorWhenNotImplemented(t *testing.T) {
+	err := fetchAndUnpack(context.Background(), resolvedRef{}, "/tmp/store")
+	require.Error(t, err)
+	ie, ok := installerErrors.AsInstallerError(err)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrCodeSystem, ie.Code())
+}
@@ -40,6 +40,21 @@ var (
 	}
 )
 
+// EventKind identifies what kind of process lifecycle event a Process
+// represents, since Copy now translates more than exec/fork events.
+type EventKind uint8
+
+const (
+	// EventKindExec is a process exec.
+	EventKindExec EventKind = iota
+	// EventKindFork is a process fork.
+	EventKindFork
+	// EventKindExit is a process exit.
+	EventKindExit
+	// EventKindSignal is a signal sent to a process.
+	EventKindSignal
+)
+
 // Process is a process
 type Process struct {
 	Pid         uint32
@@ -47,6 +62,20 @@ type Process struct {
 	ContainerID *intern.Value
 	StartTime   int64
 	Expiry      int64
+
+	// EventKind identifies what this Process value represents: an exec,
+	// fork, exit, or signal. Consumers that only care about exec/fork can
+	// ignore it, since that was the only kind Copy produced before exit
+	// and signal events were added.
+	EventKind EventKind
+	// ExitCode is set for EventKindExit.
+	ExitCode uint32
+	// Signal is the signal number sent to the process, set for
+	// EventKindSignal.
+	Signal int32
+	// Duration is the process's lifetime, set for EventKindExit when both
+	// its exec and exit times are known.
+	Duration time.Duration
 }
 
 // Init initializes the events package
@@ -72,6 +101,15 @@ type ProcessEventHandler interface {
 	HandleProcessEvent(*Process)
 }
 
+// ProcessExitHandler is an optional capability a ProcessEventHandler can
+// implement to receive exit and signal events through a dedicated callback
+// instead of the exec/fork-oriented HandleProcessEvent. Handlers that don't
+// implement it keep receiving those events through HandleProcessEvent,
+// unchanged from before exit/signal events existed.
+type ProcessExitHandler interface {
+	HandleProcessExit(*Process)
+}
+
 // RegisterHandler registers a handler function for getting process events
 func RegisterHandler(handler ProcessEventHandler) {
 	m := theMonitor.Load().(*eventMonitor)
@@ -113,16 +151,38 @@ func (h *eventHandlerWrapper) Copy(ev *model.Event) any {
 
 	// If this consumer subscribes to more event types, this block will have to account for those additional event types
 	var processStartTime time.Time
-	if ev.GetEventType() == model.ExecEventType {
+	var kind EventKind
+	var exitCode uint32
+	var signal int32
+	var duration time.Duration
+
+	switch ev.GetEventType() {
+	case model.ExecEventType:
+		kind = EventKindExec
 		processStartTime = ev.GetProcessExecTime()
-	}
-	if ev.GetEventType() == model.ForkEventType {
+	case model.ForkEventType:
+		kind = EventKindFork
 		processStartTime = ev.GetProcessForkTime()
+	case model.ExitEventType:
+		kind = EventKindExit
+		processStartTime = ev.GetProcessExecTime()
+		exitCode = ev.GetExitCode()
+		if exitTime := ev.GetExitTime(); !processStartTime.IsZero() && !exitTime.IsZero() {
+			duration = exitTime.Sub(processStartTime)
+		}
+	case model.SignalEventType:
+		kind = EventKindSignal
+		processStartTime = ev.GetProcessExecTime()
+		signal = int32(ev.GetSignalType())
 	}
 
 	p := &Process{
 		Pid:       ev.GetProcessPid(),
 		StartTime: processStartTime.UnixNano(),
+		EventKind: kind,
+		ExitCode:  exitCode,
+		Signal:    signal,
+		Duration:  duration,
 	}
 
 	envs := model.FilterEnvs(ev.GetProcessEnvp(), envFilter)
@@ -174,6 +234,13 @@ func (e *eventMonitor) HandleEvent(ev *Process) {
 	defer e.Unlock()
 
 	for _, h := range e.handlers {
+		switch ev.EventKind {
+		case EventKindExit, EventKindSignal:
+			if eh, ok := h.(ProcessExitHandler); ok {
+				eh.HandleProcessExit(ev)
+				continue
+			}
+		}
 		h.HandleProcessEvent(ev)
 	}
 }